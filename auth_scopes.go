@@ -0,0 +1,40 @@
+package res
+
+import "encoding/json"
+
+// tokenWithScopes wraps a token value together with the scopes an
+// Authorizer will later check, so that TokenScopes can read them back
+// without the caller having to encode them into the token itself.
+type tokenWithScopes struct {
+	Token  interface{} `json:"token"`
+	Scopes []string    `json:"scopes"`
+}
+
+// SetTokenWithScopes sets a new token for the client connection, same as
+// r.OK would for a plain token, but additionally records the scopes that
+// an Authorizer's rules are matched against for subsequent access and
+// call requests on the connection. AuthRequest is an interface, so this
+// is a free function rather than a method on it.
+func SetTokenWithScopes(r AuthRequest, token interface{}, scopes []string) {
+	r.OK(tokenWithScopes{Token: token, Scopes: scopes})
+}
+
+// TokenScopes returns the scopes set on the request's token through
+// SetTokenWithScopes. It returns nil if the token carries no scopes.
+func TokenScopes(r AuthRequest) []string {
+	return tokenScopes(r.RawToken())
+}
+
+// tokenScopes extracts the scopes from a raw token previously set with
+// SetTokenWithScopes. It is shared by AuthRequest and AccessRequest, both
+// of which may see the token on later requests from the same connection.
+func tokenScopes(raw json.RawMessage) []string {
+	if len(raw) == 0 {
+		return nil
+	}
+	var tws tokenWithScopes
+	if err := json.Unmarshal(raw, &tws); err != nil {
+		return nil
+	}
+	return tws.Scopes
+}
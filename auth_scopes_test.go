@@ -0,0 +1,58 @@
+package res
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// fakeAuthRequest is a minimal AuthRequest implementation used to test
+// SetTokenWithScopes/TokenScopes without a full Session/runTest harness.
+type fakeAuthRequest struct {
+	token  json.RawMessage
+	result interface{}
+}
+
+func (r *fakeAuthRequest) OK(result interface{})        { r.result = result }
+func (r *fakeAuthRequest) NotFound()                    {}
+func (r *fakeAuthRequest) MethodNotFound()              {}
+func (r *fakeAuthRequest) InvalidParams(message string) {}
+func (r *fakeAuthRequest) Error(err *Error)             {}
+func (r *fakeAuthRequest) RawParams() json.RawMessage   { return nil }
+func (r *fakeAuthRequest) RawToken() json.RawMessage    { return r.token }
+func (r *fakeAuthRequest) ParseParams(p interface{})    {}
+func (r *fakeAuthRequest) ParseToken(o interface{})     {}
+
+func TestAuthRequestTokenScopes(t *testing.T) {
+	r := &fakeAuthRequest{}
+	SetTokenWithScopes(r, map[string]string{"user": "foo"}, []string{"user", "admin"})
+
+	// SetTokenWithScopes wraps the token for OK the same way OK would be
+	// called with a plain token, so round-trip it through JSON the same
+	// way a real connection would before reading it back with RawToken.
+	raw, err := json.Marshal(r.result)
+	if err != nil {
+		t.Fatalf("failed to marshal result: %s", err)
+	}
+	r.token = raw
+
+	scopes := TokenScopes(r)
+	if len(scopes) != 2 || scopes[0] != "user" || scopes[1] != "admin" {
+		t.Errorf("expected scopes [user admin], got: %v", scopes)
+	}
+}
+
+func TestAuthRequestTokenScopesWithNoToken(t *testing.T) {
+	r := &fakeAuthRequest{}
+	scopes := TokenScopes(r)
+	if scopes != nil {
+		t.Errorf("expected nil scopes with no token, got: %v", scopes)
+	}
+}
+
+func TestAuthRequestTokenScopesWithPlainToken(t *testing.T) {
+	r := &fakeAuthRequest{token: json.RawMessage(`{"user":"foo"}`)}
+	scopes := TokenScopes(r)
+	if scopes != nil {
+		t.Errorf("expected nil scopes for a token set without SetTokenWithScopes, got: %v", scopes)
+	}
+}
@@ -0,0 +1,158 @@
+package res
+
+import "strings"
+
+// ErrAccessDenied is returned by an Authorizer when none of its rules
+// grant access to the requested resource and action.
+var ErrAccessDenied = &Error{Code: "system.accessDenied", Message: "Access denied"}
+
+// Account represents the identity performing a request, as parsed from
+// the request token by an auth handler. Scopes are typically populated
+// through SetTokenWithScopes.
+type Account struct {
+	// Scopes held by the account, such as "user" or "admin".
+	Scopes []string
+}
+
+// HasScope reports whether the account holds the given scope.
+func (a *Account) HasScope(scope string) bool {
+	if a == nil {
+		return false
+	}
+	for _, s := range a.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Rule describes a single access policy entry. ResourcePattern is matched
+// against the resource ID using the same wildcard rules as NATS subjects
+// (* matches a single token, > matches one or more trailing tokens).
+// Scope is the account scope the rule applies to, and Access is a
+// colon-separated list of actions the scope is granted, such as
+// "get:call" or "call:*".
+type Rule struct {
+	// ResourcePattern to match against the resource ID, eg. "test.model.>".
+	ResourcePattern string
+	// Scope required for the rule to apply, eg. "admin".
+	Scope string
+	// Access granted by the rule, eg. "call:*", "get", or "get:call".
+	Access string
+}
+
+// matchesResource reports whether the rule's ResourcePattern matches rid.
+func (r Rule) matchesResource(rid string) bool {
+	return subjectMatch(r.ResourcePattern, rid)
+}
+
+// grants reports whether the rule's Access list contains action, or the
+// wildcard "*".
+func (r Rule) grants(action string) bool {
+	for _, a := range strings.Split(r.Access, ":") {
+		if a == "*" || a == action {
+			return true
+		}
+	}
+	return false
+}
+
+// Authorizer is a rule-based access control policy. It is used to derive
+// access responses for resources without having to hand-roll checks in
+// every Auth or Access handler.
+//
+// Rules are evaluated in order. Access is granted as soon as a rule whose
+// ResourcePattern matches the resource, and whose Scope is empty or held
+// by the account, grants the requested action; a matching rule that does
+// not grant it is skipped, and later rules are still evaluated.
+type Authorizer struct {
+	Rules []Rule
+}
+
+// NewAuthorizer creates a new Authorizer with the given rules.
+func NewAuthorizer(rules ...Rule) *Authorizer {
+	return &Authorizer{Rules: rules}
+}
+
+// Verify checks if account is allowed to perform action on resource,
+// returning ErrAccessDenied if no rule grants access, or nil if allowed.
+func (a *Authorizer) Verify(account *Account, resource, action string) error {
+	if a != nil {
+		for _, rule := range a.Rules {
+			if !rule.matchesResource(resource) {
+				continue
+			}
+			if rule.Scope != "" && !account.HasScope(rule.Scope) {
+				continue
+			}
+			if rule.grants(action) {
+				return nil
+			}
+		}
+	}
+	return ErrAccessDenied
+}
+
+// RequireScope returns an AccessHandler that denies access to accounts
+// not holding the given scope. For accounts holding it, get and call
+// access to the resource are decided by the Authorizer set on the service
+// with SetAuthorizer. It is meant to be passed to Access, eg.
+// s.Handle("model.$id", res.Access(res.RequireScope("user"))).
+//
+// If the service has no Authorizer set, a default rule granting the
+// scope unrestricted access to every resource is used instead, so that
+// RequireScope works as a standalone, per-resource-rule-free check.
+func RequireScope(scope string) AccessHandler {
+	fallback := NewAuthorizer(Rule{ResourcePattern: ">", Scope: scope, Access: "get:call"})
+
+	return func(r AccessRequest) {
+		a := r.Service().Authorizer()
+		if a == nil {
+			a = fallback
+		}
+
+		account := &Account{Scopes: tokenScopes(r.RawToken())}
+		if !account.HasScope(scope) {
+			r.AccessDenied()
+			return
+		}
+		resource := r.ResourceName()
+
+		getErr := a.Verify(account, resource, "get")
+		callErr := a.Verify(account, resource, "call")
+		if getErr != nil && callErr != nil {
+			r.AccessDenied()
+			return
+		}
+
+		// The call action is granted as the "*" method pattern, matching
+		// any method; callErr != nil means no method is granted.
+		call := ""
+		if callErr == nil {
+			call = "*"
+		}
+		r.Access(getErr == nil, call)
+	}
+}
+
+// subjectMatch reports whether subject matches the NATS-style wildcard
+// pattern, where "*" matches exactly one token and ">" matches one or
+// more trailing tokens.
+func subjectMatch(pattern, subject string) bool {
+	pt := strings.Split(pattern, ".")
+	st := strings.Split(subject, ".")
+
+	for i, p := range pt {
+		if p == ">" {
+			return i < len(st)
+		}
+		if i >= len(st) {
+			return false
+		}
+		if p != "*" && p != st[i] {
+			return false
+		}
+	}
+	return len(pt) == len(st)
+}
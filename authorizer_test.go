@@ -0,0 +1,86 @@
+package res
+
+import "testing"
+
+func TestAuthorizerVerifyAllow(t *testing.T) {
+	a := NewAuthorizer(Rule{
+		ResourcePattern: "test.model.>",
+		Scope:           "user",
+		Access:          "get:call",
+	})
+
+	err := a.Verify(&Account{Scopes: []string{"user"}}, "test.model.42", "get")
+	if err != nil {
+		t.Errorf("expected access to be granted, got error: %s", err)
+	}
+}
+
+func TestAuthorizerVerifyDeniedWithoutScope(t *testing.T) {
+	a := NewAuthorizer(Rule{
+		ResourcePattern: "test.model.>",
+		Scope:           "admin",
+		Access:          "get:call",
+	})
+
+	err := a.Verify(&Account{Scopes: []string{"user"}}, "test.model.42", "get")
+	if err != ErrAccessDenied {
+		t.Errorf("expected ErrAccessDenied, got: %v", err)
+	}
+}
+
+func TestAuthorizerVerifyDeniedWithoutMatchingAction(t *testing.T) {
+	a := NewAuthorizer(Rule{
+		ResourcePattern: "test.model.>",
+		Scope:           "user",
+		Access:          "get",
+	})
+
+	err := a.Verify(&Account{Scopes: []string{"user"}}, "test.model.42", "call")
+	if err != ErrAccessDenied {
+		t.Errorf("expected ErrAccessDenied, got: %v", err)
+	}
+}
+
+func TestAuthorizerVerifyWildcardAccess(t *testing.T) {
+	a := NewAuthorizer(Rule{
+		ResourcePattern: "test.model.>",
+		Scope:           "admin",
+		Access:          "call:*",
+	})
+
+	if err := a.Verify(&Account{Scopes: []string{"admin"}}, "test.model.42", "call"); err != nil {
+		t.Errorf("expected call access to be granted, got error: %s", err)
+	}
+	if err := a.Verify(&Account{Scopes: []string{"admin"}}, "test.model.42", "get"); err != nil {
+		t.Errorf("expected get access to be granted by wildcard, got error: %s", err)
+	}
+}
+
+func TestAuthorizerVerifyNoRulesDenies(t *testing.T) {
+	a := NewAuthorizer()
+	if err := a.Verify(&Account{Scopes: []string{"user"}}, "test.model.42", "get"); err != ErrAccessDenied {
+		t.Errorf("expected ErrAccessDenied with no rules, got: %v", err)
+	}
+}
+
+func TestSubjectMatchWildcard(t *testing.T) {
+	tbl := []struct {
+		Pattern string
+		Subject string
+		Match   bool
+	}{
+		{"test.model.>", "test.model.42", true},
+		{"test.model.>", "test.model.42.foo", true},
+		{"test.model.>", "test.collection.42", false},
+		{"test.model.*", "test.model.42", true},
+		{"test.model.*", "test.model.42.foo", false},
+		{"test.model.42", "test.model.42", true},
+		{"test.model.42", "test.model.43", false},
+	}
+
+	for _, l := range tbl {
+		if m := subjectMatch(l.Pattern, l.Subject); m != l.Match {
+			t.Errorf("subjectMatch(%q, %q) = %v, expected %v", l.Pattern, l.Subject, m, l.Match)
+		}
+	}
+}
@@ -1,9 +1,34 @@
 package res
 
-import nats "github.com/nats-io/nats.go"
+// IncomingMsg is a transport-agnostic representation of an incoming
+// message, carrying just enough information for Service to dispatch it:
+// the subject it was published on, the reply subject to respond on (if
+// any), and the raw payload.
+//
+// It exists so that the Conn interface isn't tied to *nats.Msg, allowing
+// alternate transports, such as an in-memory implementation used in
+// tests, to implement Conn without depending on nats.go.
+type IncomingMsg struct {
+	// Subject the message was published on.
+	Subject string
+	// Reply is the subject to publish any response on. Empty if the
+	// message expects no reply.
+	Reply string
+	// Data is the message payload.
+	Data []byte
+}
+
+// Subscription represents a subscription to one or more subjects.
+type Subscription interface {
+	// Unsubscribe removes the subscription.
+	Unsubscribe() error
+}
 
-// Conn is an interface that represents a connection to a NATS server.
-// It is implemented by nats.Conn.
+// Conn is an interface that represents a connection to a messaging
+// system. It is implemented by the NATS-backed connection returned by
+// NewNATSConn, as well as by the in-memory connection in the restest
+// package used for testing and embedded deployments without a NATS
+// server.
 type Conn interface {
 	// Publish publishes the data argument to the given subject
 	Publish(subject string, payload []byte) error
@@ -13,13 +38,13 @@ type Conn interface {
 	PublishRequest(subject, reply string, data []byte) error
 
 	// ChanSubscribe subscribes to messages matching the subject pattern.
-	ChanSubscribe(subject string, ch chan *nats.Msg) (*nats.Subscription, error)
+	ChanSubscribe(subject string, ch chan *IncomingMsg) (Subscription, error)
 
 	// ChanQueueSubscribe subscribes to messages matching the subject pattern.
 	// All subscribers with the same queue name will form the queue group and
 	// only one member of the group will be selected to receive any given
 	// message, which will be placed on the channel.
-	ChanQueueSubscribe(subject, queue string, ch chan *nats.Msg) (*nats.Subscription, error)
+	ChanQueueSubscribe(subject, queue string, ch chan *IncomingMsg) (Subscription, error)
 
 	// Close will close the connection to the server.
 	Close()
@@ -3,6 +3,7 @@ package resbadger
 import (
 	"bytes"
 	"fmt"
+	"sort"
 
 	"github.com/dgraph-io/badger"
 	res "github.com/jirenius/go-res"
@@ -41,11 +42,17 @@ type IndexSet struct {
 }
 
 // IndexQuery represents a query towards an index.
+//
+// KeyRange, when set, is used in place of KeyPrefix to scan a range of
+// index keys rather than a single prefix. This allows queries such as an
+// age range, where the indexed value is sortable but not a common prefix.
 type IndexQuery struct {
 	// Index used
 	Index Index
-	// KeyPrefix to match against the index key
+	// KeyPrefix to match against the index key. Ignored if KeyRange is set.
 	KeyPrefix []byte
+	// KeyRange to scan the index key over. Takes precedence over KeyPrefix.
+	KeyRange *KeyRange
 	// FilterKeys for keys in the query collection. May be nil.
 	FilterKeys func(key []byte) bool
 	// Offset from which item to start.
@@ -56,6 +63,55 @@ type IndexQuery struct {
 	Reverse bool
 }
 
+// KeyRange represents a, possibly unbounded, range of index key values to
+// scan, used as an alternative to IndexQuery.KeyPrefix.
+type KeyRange struct {
+	// Start of the range. Nil means no lower bound.
+	Start []byte
+	// End of the range. Nil means no upper bound.
+	End []byte
+	// StartInclusive tells if Start itself is included in the range.
+	StartInclusive bool
+	// EndInclusive tells if End itself is included in the range.
+	EndInclusive bool
+}
+
+// IntersectMode tells how the sub-queries of a CompositeQuery are to be
+// combined.
+type IntersectMode byte
+
+const (
+	// Intersect includes only resource IDs found in the result of every
+	// sub-query.
+	Intersect IntersectMode = iota
+	// Union includes resource IDs found in the result of any sub-query.
+	Union
+)
+
+// CompositeQuery combines the results of multiple IndexQuery values,
+// possibly spanning different indexes, into a single query result.
+//
+// A sub-query pinned to a single exact index value (a KeyRange with
+// equal, inclusive Start and End) is streamed directly from its badger
+// iterator without materializing a lookup set, since its entries are
+// already visited in ascending RID order. A sub-query that may span
+// more than one value is fetched in full and sorted by RID first,
+// since index entries are ordered by value before RID. The resulting
+// streams are then merged in lock-step, giving O(n·k) merge cost for n
+// total entries over k sub-queries.
+type CompositeQuery struct {
+	// Queries to combine.
+	Queries []IndexQuery
+	// Mode tells how the Queries are combined.
+	Mode IntersectMode
+	// Offset from which item to start, applied after combining.
+	Offset int
+	// Limit how many items to read. Negative means unlimited.
+	Limit int
+	// Reverse flag to tell if the combined order is reversed.
+	Reverse bool
+}
+
 type indexListener struct {
 	cb   func(r res.Resource, before, after interface{})
 	name string
@@ -130,6 +186,26 @@ func (idx Index) getQuery(keyPrefix []byte) []byte {
 
 // FetchCollection fetches a collection of resource references based on the query.
 func (iq *IndexQuery) FetchCollection(db *badger.DB) ([]res.Ref, error) {
+	if iq.Limit == 0 {
+		return nil, nil
+	}
+
+	var result []res.Ref
+	if err := db.View(func(txn *badger.Txn) error {
+		refs, err := iq.fetchCollectionTxn(txn)
+		result = refs
+		return err
+	}); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// fetchCollectionTxn is the core of FetchCollection, taking an existing
+// transaction so CompositeQuery can reuse it without nesting db.View
+// calls.
+func (iq *IndexQuery) fetchCollectionTxn(txn *badger.Txn) ([]res.Ref, error) {
 	offset := iq.Offset
 	limit := iq.Limit
 
@@ -150,55 +226,415 @@ func (iq *IndexQuery) FetchCollection(db *badger.DB) ([]res.Ref, error) {
 	}
 	result := make([]res.Ref, 0, buf)
 
+	filter := iq.FilterKeys
+	namelen := len(iq.Index.Name) + 1
+
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	opts.Reverse = iq.Reverse
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	var err error
+	if iq.KeyRange != nil {
+		err = iq.fetchRange(it, namelen, filter, &offset, &limit, &result)
+	} else {
+		err = iq.fetchPrefix(it, namelen, filter, &offset, &limit, &result)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// fetchPrefix iterates the index matching IndexQuery.KeyPrefix, the
+// original query mode supported before range queries were added.
+func (iq *IndexQuery) fetchPrefix(it *badger.Iterator, namelen int, filter func([]byte) bool, offset, limit *int, result *[]res.Ref) error {
 	queryPrefix := iq.Index.getQuery(iq.KeyPrefix)
 	qplen := len(queryPrefix)
 
-	filter := iq.FilterKeys
-	namelen := len(iq.Index.Name) + 1
+	for it.Seek(queryPrefix); it.ValidForPrefix(queryPrefix); it.Next() {
+		k := it.Item().Key()
+		ridIdx := bytes.LastIndexByte(k, ridSeparator)
+		if ridIdx < 0 {
+			return fmt.Errorf("index entry [%s] is invalid", k)
+		}
+		// Validate that a query with ?-mark isn't mistaken for a hit
+		// when matching the ? separator for the resource ID.
+		if qplen > ridIdx {
+			continue
+		}
 
-	if err := db.View(func(txn *badger.Txn) error {
-		opts := badger.DefaultIteratorOptions
-		opts.PrefetchValues = false
-		opts.Reverse = iq.Reverse
-		it := txn.NewIterator(opts)
-		defer it.Close()
-		for it.Seek(queryPrefix); it.ValidForPrefix(queryPrefix); it.Next() {
-			k := it.Item().Key()
-			idx := bytes.LastIndexByte(k, ridSeparator)
-			if idx < 0 {
-				return fmt.Errorf("index entry [%s] is invalid", k)
+		if !matchAndCollect(k, ridIdx, namelen, filter, offset, limit, result) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// fetchRange iterates the index across IndexQuery.KeyRange, stopping as
+// soon as the iteration moves past the bound opposite of the seek
+// direction, since index entries are visited in sorted key order.
+func (iq *IndexQuery) fetchRange(it *badger.Iterator, namelen int, filter func([]byte) bool, offset, limit *int, result *[]res.Ref) error {
+	kr := iq.KeyRange
+	basePrefix := iq.Index.getQuery(nil)
+	qplen := len(basePrefix)
+
+	var seek []byte
+	if iq.Reverse {
+		if kr.End != nil {
+			seek = append(iq.Index.getQuery(kr.End), 0xff)
+		} else {
+			seek = append(append([]byte{}, basePrefix...), 0xff)
+		}
+	} else {
+		if kr.Start != nil {
+			seek = iq.Index.getQuery(kr.Start)
+		} else {
+			seek = basePrefix
+		}
+	}
+
+	for it.Seek(seek); it.ValidForPrefix(basePrefix); it.Next() {
+		k := it.Item().Key()
+		ridIdx := bytes.LastIndexByte(k, ridSeparator)
+		if ridIdx < 0 {
+			return fmt.Errorf("index entry [%s] is invalid", k)
+		}
+		if qplen > ridIdx {
+			continue
+		}
+
+		value := k[namelen:ridIdx]
+		if !keyAboveStart(value, kr) {
+			if iq.Reverse {
+				break
 			}
-			// Validate that a query with ?-mark isn't mistaken for a hit
-			// when matching the ? separator for the resource ID.
-			if qplen > idx {
+			continue
+		}
+		if !keyBelowEnd(value, kr) {
+			if iq.Reverse {
 				continue
 			}
+			break
+		}
 
-			// If we have a key filter, validate against it
-			if filter != nil {
-				if !filter(k[namelen:idx]) {
-					continue
-				}
-			}
+		if !matchAndCollect(k, ridIdx, namelen, filter, offset, limit, result) {
+			return nil
+		}
+	}
+	return nil
+}
 
-			// Skip until we reach the offset we are searching from
-			if offset > 0 {
-				offset--
-				continue
-			}
+// keyAboveStart reports whether value is within the lower bound of kr.
+func keyAboveStart(value []byte, kr *KeyRange) bool {
+	if kr.Start == nil {
+		return true
+	}
+	c := bytes.Compare(value, kr.Start)
+	if c > 0 {
+		return true
+	}
+	return c == 0 && kr.StartInclusive
+}
 
-			// Add resource ID reference to result
-			result = append(result, res.Ref(k[idx+1:]))
+// keyBelowEnd reports whether value is within the upper bound of kr.
+func keyBelowEnd(value []byte, kr *KeyRange) bool {
+	if kr.End == nil {
+		return true
+	}
+	c := bytes.Compare(value, kr.End)
+	if c < 0 {
+		return true
+	}
+	return c == 0 && kr.EndInclusive
+}
 
-			limit--
-			if limit == 0 {
-				return nil
+// matchAndCollect applies the key filter, offset and limit to a single
+// index entry, appending its resource ID reference to result when it
+// counts as a hit. It returns false once limit has been reached, telling
+// the caller to stop iterating.
+func matchAndCollect(k []byte, ridIdx, namelen int, filter func([]byte) bool, offset, limit *int, result *[]res.Ref) bool {
+	// If we have a key filter, validate against it
+	if filter != nil {
+		if !filter(k[namelen:ridIdx]) {
+			return true
+		}
+	}
+
+	// Skip until we reach the offset we are searching from
+	if *offset > 0 {
+		*offset--
+		return true
+	}
+
+	// Add resource ID reference to result
+	*result = append(*result, res.Ref(k[ridIdx+1:]))
+
+	*limit--
+	return *limit != 0
+}
+
+// FetchCollection fetches a collection of resource references by
+// combining the result of each sub-query according to Mode.
+func (cq *CompositeQuery) FetchCollection(db *badger.DB) ([]res.Ref, error) {
+	if len(cq.Queries) == 0 || cq.Limit == 0 {
+		return nil, nil
+	}
+
+	var merged []res.Ref
+	if err := db.View(func(txn *badger.Txn) error {
+		streams := make([]refStream, len(cq.Queries))
+		defer func() {
+			for _, s := range streams {
+				if s != nil {
+					s.Close()
+				}
 			}
+		}()
+		for i := range cq.Queries {
+			s, err := newRefStream(txn, &cq.Queries[i])
+			if err != nil {
+				return err
+			}
+			streams[i] = s
+		}
+
+		if cq.Mode == Union {
+			merged = mergeStreamsUnion(streams)
+		} else {
+			merged = mergeStreamsIntersect(streams)
 		}
 		return nil
 	}); err != nil {
 		return nil, err
 	}
 
-	return result, nil
+	if cq.Reverse {
+		for l, r := 0, len(merged)-1; l < r; l, r = l+1, r-1 {
+			merged[l], merged[r] = merged[r], merged[l]
+		}
+	}
+
+	offset := cq.Offset
+	if offset > 0 {
+		if offset >= len(merged) {
+			return nil, nil
+		}
+		merged = merged[offset:]
+	}
+	if cq.Limit > 0 && cq.Limit < len(merged) {
+		merged = merged[:cq.Limit]
+	}
+
+	return merged, nil
+}
+
+// refStream iterates a single sub-query's resource ID references in
+// ascending RID order, used by CompositeQuery to merge sub-queries in
+// lock-step.
+type refStream interface {
+	// Peek returns the current reference without advancing, or false if
+	// the stream is exhausted.
+	Peek() (res.Ref, bool)
+	// Next advances to the following reference.
+	Next()
+	// Close releases resources held by the stream.
+	Close() error
+}
+
+// isExactValue reports whether iq is guaranteed to match at most one
+// distinct index value. Index keys are ordered by value first and RID
+// second, so only a query pinned to a single value visits its entries
+// in ascending RID order without further sorting.
+func isExactValue(iq *IndexQuery) bool {
+	kr := iq.KeyRange
+	return kr != nil && kr.Start != nil && kr.StartInclusive && kr.EndInclusive && bytes.Equal(kr.Start, kr.End)
+}
+
+// newRefStream returns the refStream best suited for iq: an iterStream,
+// streamed straight from badger with no materialization, when iq is
+// pinned to a single exact value, or a sliceStream, fetched and sorted
+// up front, for any sub-query that may span more than one value.
+func newRefStream(txn *badger.Txn, iq *IndexQuery) (refStream, error) {
+	if isExactValue(iq) {
+		return newIterStream(txn, iq), nil
+	}
+	return newSliceStream(txn, iq)
+}
+
+// iterStream is a refStream backed directly by a badger.Iterator,
+// for sub-queries pinned to a single exact index value.
+type iterStream struct {
+	it          *badger.Iterator
+	queryPrefix []byte
+	namelen     int
+	filter      func([]byte) bool
+	cur         res.Ref
+	ok          bool
+}
+
+func newIterStream(txn *badger.Txn, iq *IndexQuery) *iterStream {
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	it := txn.NewIterator(opts)
+
+	s := &iterStream{
+		it:          it,
+		queryPrefix: iq.Index.getQuery(iq.KeyRange.Start),
+		namelen:     len(iq.Index.Name) + 1,
+		filter:      iq.FilterKeys,
+	}
+	it.Seek(s.queryPrefix)
+	s.advance()
+	return s
+}
+
+// advance scans forward from the iterator's current position to the
+// next matching entry. Only entries whose index value equals the pinned
+// value exactly match, so the separator must directly follow the query
+// prefix; longer values sharing it as a prefix are skipped.
+func (s *iterStream) advance() {
+	qplen := len(s.queryPrefix)
+	for ; s.it.ValidForPrefix(s.queryPrefix); s.it.Next() {
+		k := s.it.Item().Key()
+		ridIdx := bytes.LastIndexByte(k, ridSeparator)
+		if ridIdx != qplen {
+			continue
+		}
+		if s.filter != nil && !s.filter(k[s.namelen:ridIdx]) {
+			continue
+		}
+		s.cur = res.Ref(append([]byte(nil), k[ridIdx+1:]...))
+		s.ok = true
+		s.it.Next()
+		return
+	}
+	s.ok = false
+}
+
+func (s *iterStream) Peek() (res.Ref, bool) { return s.cur, s.ok }
+
+func (s *iterStream) Next() { s.advance() }
+
+func (s *iterStream) Close() error {
+	s.it.Close()
+	return nil
+}
+
+// sliceStream is a refStream backed by a pre-fetched, sorted slice of
+// references, the fallback for sub-queries that may span more than one
+// distinct index value.
+type sliceStream struct {
+	refs []res.Ref
+}
+
+func newSliceStream(txn *badger.Txn, iq *IndexQuery) (*sliceStream, error) {
+	q := *iq
+	// The sub-query is fetched in full and sorted here, so any
+	// offset/limit/reverse of its own would only get in the way.
+	q.Offset = 0
+	q.Limit = -1
+	q.Reverse = false
+
+	refs, err := q.fetchCollectionTxn(txn)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(refs, func(a, b int) bool { return refs[a] < refs[b] })
+	return &sliceStream{refs: refs}, nil
+}
+
+func (s *sliceStream) Peek() (res.Ref, bool) {
+	if len(s.refs) == 0 {
+		return "", false
+	}
+	return s.refs[0], true
+}
+
+func (s *sliceStream) Next() {
+	if len(s.refs) > 0 {
+		s.refs = s.refs[1:]
+	}
+}
+
+func (s *sliceStream) Close() error { return nil }
+
+// mergeStreamsIntersect merges k ascending refStreams, keeping only the
+// references present in every stream. It advances all streams in
+// lock-step, doing O(n·k) comparisons for n total references across k
+// streams.
+func mergeStreamsIntersect(streams []refStream) []res.Ref {
+	n := len(streams)
+	var result []res.Ref
+
+	for {
+		var max res.Ref
+		for i := 0; i < n; i++ {
+			v, ok := streams[i].Peek()
+			if !ok {
+				return result
+			}
+			if v > max {
+				max = v
+			}
+		}
+
+		allEqual := true
+		for i := 0; i < n; i++ {
+			v, ok := streams[i].Peek()
+			for ok && v < max {
+				streams[i].Next()
+				v, ok = streams[i].Peek()
+			}
+			if !ok {
+				return result
+			}
+			if v != max {
+				allEqual = false
+			}
+		}
+
+		if allEqual {
+			result = append(result, max)
+			for i := 0; i < n; i++ {
+				streams[i].Next()
+			}
+		}
+	}
+}
+
+// mergeStreamsUnion merges k ascending refStreams, keeping the
+// references present in any stream, deduplicated.
+func mergeStreamsUnion(streams []refStream) []res.Ref {
+	n := len(streams)
+	var result []res.Ref
+	var last res.Ref
+	hasLast := false
+
+	for {
+		var min res.Ref
+		found := false
+		for i := 0; i < n; i++ {
+			if v, ok := streams[i].Peek(); ok && (!found || v < min) {
+				min = v
+				found = true
+			}
+		}
+		if !found {
+			return result
+		}
+
+		if !hasLast || min != last {
+			result = append(result, min)
+			last = min
+			hasLast = true
+		}
+		for i := 0; i < n; i++ {
+			if v, ok := streams[i].Peek(); ok && v == min {
+				streams[i].Next()
+			}
+		}
+	}
 }
@@ -0,0 +1,357 @@
+package resbadger
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/dgraph-io/badger"
+	res "github.com/jirenius/go-res"
+)
+
+// openTestDB opens a badger database in a temporary directory that is
+// removed when the test completes.
+func openTestDB(t *testing.T) *badger.DB {
+	dir, err := ioutil.TempDir("", "resbadger-index-test")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %s", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	db, err := badger.Open(badger.DefaultOptions(dir))
+	if err != nil {
+		t.Fatalf("failed to open badger db: %s", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
+
+// putIndexEntries writes one index entry per {value, rid} pair directly,
+// mirroring what the index maintenance logic would write for a model.
+func putIndexEntries(t *testing.T, db *badger.DB, idx Index, entries map[string]string) {
+	t.Helper()
+	err := db.Update(func(txn *badger.Txn) error {
+		for rid, value := range entries {
+			k := idx.getKey([]byte(rid), []byte(value))
+			if err := txn.Set(k, nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("failed to write index entries: %s", err)
+	}
+}
+
+func assertRefs(t *testing.T, got []res.Ref, want ...string) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("expected refs %v, got %v", want, got)
+	}
+	for i, w := range want {
+		if string(got[i]) != w {
+			t.Errorf("expected refs %v, got %v", want, got)
+			return
+		}
+	}
+}
+
+func ageIndex() Index {
+	return Index{Name: "age"}
+}
+
+func TestIndexQueryKeyRangeInclusive(t *testing.T) {
+	db := openTestDB(t)
+	idx := ageIndex()
+	putIndexEntries(t, db, idx, map[string]string{
+		"user.1": "20",
+		"user.2": "25",
+		"user.3": "30",
+		"user.4": "40",
+	})
+
+	q := IndexQuery{
+		Index: idx,
+		KeyRange: &KeyRange{
+			Start:          []byte("20"),
+			End:            []byte("30"),
+			StartInclusive: true,
+			EndInclusive:   true,
+		},
+		Limit: -1,
+	}
+	refs, err := q.FetchCollection(db)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	assertRefs(t, refs, "user.1", "user.2", "user.3")
+}
+
+func TestIndexQueryKeyRangeExclusive(t *testing.T) {
+	db := openTestDB(t)
+	idx := ageIndex()
+	putIndexEntries(t, db, idx, map[string]string{
+		"user.1": "20",
+		"user.2": "25",
+		"user.3": "30",
+	})
+
+	q := IndexQuery{
+		Index: idx,
+		KeyRange: &KeyRange{
+			Start: []byte("20"),
+			End:   []byte("30"),
+		},
+		Limit: -1,
+	}
+	refs, err := q.FetchCollection(db)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	assertRefs(t, refs, "user.2")
+}
+
+func TestIndexQueryKeyRangeReverse(t *testing.T) {
+	db := openTestDB(t)
+	idx := ageIndex()
+	putIndexEntries(t, db, idx, map[string]string{
+		"user.1": "20",
+		"user.2": "25",
+		"user.3": "30",
+		"user.4": "40",
+	})
+
+	q := IndexQuery{
+		Index: idx,
+		KeyRange: &KeyRange{
+			Start:          []byte("20"),
+			End:            []byte("30"),
+			StartInclusive: true,
+			EndInclusive:   true,
+		},
+		Reverse: true,
+		Limit:   -1,
+	}
+	refs, err := q.FetchCollection(db)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	assertRefs(t, refs, "user.3", "user.2", "user.1")
+}
+
+func TestIndexQueryKeyRangeEmpty(t *testing.T) {
+	db := openTestDB(t)
+	idx := ageIndex()
+	putIndexEntries(t, db, idx, map[string]string{
+		"user.1": "20",
+		"user.2": "25",
+	})
+
+	q := IndexQuery{
+		Index: idx,
+		KeyRange: &KeyRange{
+			Start: []byte("50"),
+			End:   []byte("60"),
+		},
+		Limit: -1,
+	}
+	refs, err := q.FetchCollection(db)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(refs) != 0 {
+		t.Errorf("expected no refs, got %v", refs)
+	}
+}
+
+func TestCompositeQueryIntersect(t *testing.T) {
+	db := openTestDB(t)
+	countryIdx := Index{Name: "country"}
+	ageIdx := ageIndex()
+
+	putIndexEntries(t, db, countryIdx, map[string]string{
+		"user.1": "SE",
+		"user.2": "SE",
+		"user.3": "NO",
+		"user.4": "SE",
+	})
+	putIndexEntries(t, db, ageIdx, map[string]string{
+		"user.1": "20",
+		"user.2": "30",
+		"user.3": "25",
+		"user.4": "25",
+	})
+
+	cq := CompositeQuery{
+		Queries: []IndexQuery{
+			{Index: countryIdx, KeyPrefix: []byte("SE")},
+			{
+				Index: ageIdx,
+				KeyRange: &KeyRange{
+					Start:          []byte("20"),
+					End:            []byte("25"),
+					StartInclusive: true,
+					EndInclusive:   true,
+				},
+			},
+		},
+		Mode:  Intersect,
+		Limit: -1,
+	}
+	refs, err := cq.FetchCollection(db)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	assertRefs(t, refs, "user.1", "user.4")
+}
+
+func TestCompositeQueryIntersectExactValueStream(t *testing.T) {
+	db := openTestDB(t)
+	countryIdx := Index{Name: "country"}
+	ageIdx := ageIndex()
+
+	putIndexEntries(t, db, countryIdx, map[string]string{
+		"user.1": "SE",
+		"user.2": "SE",
+		"user.3": "NO",
+		"user.4": "SE",
+	})
+	putIndexEntries(t, db, ageIdx, map[string]string{
+		"user.1": "20",
+		"user.2": "30",
+		"user.3": "25",
+		"user.4": "25",
+	})
+
+	// A KeyRange with equal, inclusive Start and End pins the country
+	// sub-query to a single exact value, so it is streamed straight from
+	// its badger iterator by newIterStream rather than fetched and
+	// sorted up front by newSliceStream.
+	cq := CompositeQuery{
+		Queries: []IndexQuery{
+			{
+				Index: countryIdx,
+				KeyRange: &KeyRange{
+					Start:          []byte("SE"),
+					End:            []byte("SE"),
+					StartInclusive: true,
+					EndInclusive:   true,
+				},
+			},
+			{
+				Index:     ageIdx,
+				KeyPrefix: []byte("25"),
+			},
+		},
+		Mode:  Intersect,
+		Limit: -1,
+	}
+	refs, err := cq.FetchCollection(db)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	assertRefs(t, refs, "user.4")
+}
+
+func TestIsExactValue(t *testing.T) {
+	tbl := []struct {
+		Name  string
+		Query IndexQuery
+		Want  bool
+	}{
+		{"no KeyRange", IndexQuery{KeyPrefix: []byte("SE")}, false},
+		{
+			"equal inclusive bounds",
+			IndexQuery{KeyRange: &KeyRange{Start: []byte("SE"), End: []byte("SE"), StartInclusive: true, EndInclusive: true}},
+			true,
+		},
+		{
+			"equal but start exclusive",
+			IndexQuery{KeyRange: &KeyRange{Start: []byte("SE"), End: []byte("SE"), EndInclusive: true}},
+			false,
+		},
+		{
+			"differing bounds",
+			IndexQuery{KeyRange: &KeyRange{Start: []byte("NO"), End: []byte("SE"), StartInclusive: true, EndInclusive: true}},
+			false,
+		},
+	}
+
+	for _, l := range tbl {
+		if got := isExactValue(&l.Query); got != l.Want {
+			t.Errorf("%s: isExactValue() = %v, expected %v", l.Name, got, l.Want)
+		}
+	}
+}
+
+func TestCompositeQueryIntersectWithFilterKeys(t *testing.T) {
+	db := openTestDB(t)
+	countryIdx := Index{Name: "country"}
+	ageIdx := ageIndex()
+
+	putIndexEntries(t, db, countryIdx, map[string]string{
+		"user.1": "SE",
+		"user.2": "SE",
+		"user.4": "SE",
+	})
+	putIndexEntries(t, db, ageIdx, map[string]string{
+		"user.1": "20",
+		"user.2": "20x",
+		"user.4": "20",
+	})
+
+	cq := CompositeQuery{
+		Queries: []IndexQuery{
+			{Index: countryIdx, KeyPrefix: []byte("SE")},
+			{
+				Index:     ageIdx,
+				KeyPrefix: []byte("20"),
+				FilterKeys: func(key []byte) bool {
+					// FilterKeys receives the index value the key was
+					// stored under, not the resource ID, so exclude
+					// user.2 by its distinguishing age value "20x"
+					// instead of its RID.
+					return string(key) != "20x"
+				},
+			},
+		},
+		Mode:  Intersect,
+		Limit: -1,
+	}
+	refs, err := cq.FetchCollection(db)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	assertRefs(t, refs, "user.1", "user.4")
+}
+
+func TestCompositeQueryUnion(t *testing.T) {
+	db := openTestDB(t)
+	countryIdx := Index{Name: "country"}
+	cityIdx := Index{Name: "city"}
+
+	putIndexEntries(t, db, countryIdx, map[string]string{
+		"user.1": "SE",
+		"user.2": "NO",
+	})
+	putIndexEntries(t, db, cityIdx, map[string]string{
+		"user.2": "Oslo",
+		"user.3": "Oslo",
+	})
+
+	cq := CompositeQuery{
+		Queries: []IndexQuery{
+			{Index: countryIdx, KeyPrefix: []byte("SE")},
+			{Index: cityIdx, KeyPrefix: []byte("Oslo")},
+		},
+		Mode:  Union,
+		Limit: -1,
+	}
+	refs, err := cq.FetchCollection(db)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	assertRefs(t, refs, "user.1", "user.2", "user.3")
+}
@@ -0,0 +1,70 @@
+package res
+
+import nats "github.com/nats-io/nats.go"
+
+// NewNATSConn wraps an existing *nats.Conn so that it implements Conn,
+// translating *nats.Msg to the transport-agnostic IncomingMsg.
+func NewNATSConn(nc *nats.Conn) Conn {
+	return &natsConn{nc: nc}
+}
+
+// natsConn adapts a *nats.Conn to the Conn interface.
+type natsConn struct {
+	nc *nats.Conn
+}
+
+func (c *natsConn) Publish(subject string, payload []byte) error {
+	return c.nc.Publish(subject, payload)
+}
+
+func (c *natsConn) PublishRequest(subject, reply string, data []byte) error {
+	return c.nc.PublishRequest(subject, reply, data)
+}
+
+func (c *natsConn) ChanSubscribe(subject string, ch chan *IncomingMsg) (Subscription, error) {
+	nch := make(chan *nats.Msg, cap(ch))
+	sub, err := c.nc.ChanSubscribe(subject, nch)
+	if err != nil {
+		return nil, err
+	}
+	go relayNATSMsgs(nch, ch)
+	return &natsSubscription{sub: sub, nch: nch}, nil
+}
+
+func (c *natsConn) ChanQueueSubscribe(subject, queue string, ch chan *IncomingMsg) (Subscription, error) {
+	nch := make(chan *nats.Msg, cap(ch))
+	sub, err := c.nc.ChanQueueSubscribe(subject, queue, nch)
+	if err != nil {
+		return nil, err
+	}
+	go relayNATSMsgs(nch, ch)
+	return &natsSubscription{sub: sub, nch: nch}, nil
+}
+
+func (c *natsConn) Close() {
+	c.nc.Close()
+}
+
+// natsSubscription wraps a *nats.Subscription together with the internal
+// *nats.Msg channel relayNATSMsgs reads from. nats.go never closes a
+// ChanSubscription's channel on Unsubscribe, so without closing nch
+// ourselves the relay goroutine started for every subscription would
+// leak forever.
+type natsSubscription struct {
+	sub *nats.Subscription
+	nch chan *nats.Msg
+}
+
+func (s *natsSubscription) Unsubscribe() error {
+	err := s.sub.Unsubscribe()
+	close(s.nch)
+	return err
+}
+
+// relayNATSMsgs converts messages received on nch to IncomingMsg and
+// forwards them on ch until nch is closed by natsSubscription.Unsubscribe.
+func relayNATSMsgs(nch chan *nats.Msg, ch chan *IncomingMsg) {
+	for m := range nch {
+		ch <- &IncomingMsg{Subject: m.Subject, Reply: m.Reply, Data: m.Data}
+	}
+}
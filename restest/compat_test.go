@@ -0,0 +1,73 @@
+package restest
+
+import (
+	"testing"
+	"time"
+
+	res "github.com/jirenius/go-res"
+)
+
+// RunConnScenarios runs a set of conformance scenarios against any
+// res.Conn implementation. It is used to verify that the in-memory Conn
+// behaves the same way as the NATS-backed Conn for the subset of
+// behavior Service relies on, so that a service can be tested against
+// either transport interchangeably.
+func RunConnScenarios(t *testing.T, newConn func() res.Conn) {
+	t.Run("Publish delivers to a matching subscriber", func(t *testing.T) {
+		c := newConn()
+		defer c.Close()
+		ch := make(chan *res.IncomingMsg, 1)
+		if _, err := c.ChanSubscribe("test.model.foo", ch); err != nil {
+			t.Fatalf("unexpected error subscribing: %s", err)
+		}
+		c.Publish("test.model.foo", []byte("data"))
+		assertMsg(t, ch, "test.model.foo", "", "data")
+	})
+
+	t.Run("PublishRequest carries the reply subject", func(t *testing.T) {
+		c := newConn()
+		defer c.Close()
+		ch := make(chan *res.IncomingMsg, 1)
+		if _, err := c.ChanSubscribe("test.model.foo", ch); err != nil {
+			t.Fatalf("unexpected error subscribing: %s", err)
+		}
+		c.PublishRequest("test.model.foo", "_INBOX.abc", []byte("data"))
+		assertMsg(t, ch, "test.model.foo", "_INBOX.abc", "data")
+	})
+
+	t.Run("ChanQueueSubscribe delivers once per queue group", func(t *testing.T) {
+		c := newConn()
+		defer c.Close()
+		ch1 := make(chan *res.IncomingMsg, 1)
+		ch2 := make(chan *res.IncomingMsg, 1)
+		c.ChanQueueSubscribe("test.model.foo", "workers", ch1)
+		c.ChanQueueSubscribe("test.model.foo", "workers", ch2)
+		c.Publish("test.model.foo", []byte("data"))
+
+		// Delivery is asynchronous over a real NATS connection, so wait
+		// for whichever member receives it instead of assuming the
+		// message is already queued by the time Publish returns.
+		select {
+		case <-ch1:
+			assertNoMsg(t, ch2)
+		case <-ch2:
+			assertNoMsg(t, ch1)
+		case <-time.After(time.Second):
+			t.Fatal("expected exactly one queue member to receive the message")
+		}
+	})
+
+	t.Run("Unsubscribe stops delivery", func(t *testing.T) {
+		c := newConn()
+		defer c.Close()
+		ch := make(chan *res.IncomingMsg, 1)
+		sub, _ := c.ChanSubscribe("test.model.foo", ch)
+		sub.Unsubscribe()
+		c.Publish("test.model.foo", []byte("data"))
+		assertNoMsg(t, ch)
+	})
+}
+
+func TestConnScenarios(t *testing.T) {
+	RunConnScenarios(t, func() res.Conn { return NewConn() })
+}
@@ -0,0 +1,162 @@
+// Package restest provides an in-memory implementation of res.Conn,
+// allowing a full RES service to be exercised in unit tests or embedded
+// deployments without running a gnatsd instance.
+package restest
+
+import (
+	"strings"
+	"sync"
+
+	res "github.com/jirenius/go-res"
+)
+
+// Conn is an in-memory, in-process implementation of res.Conn. It routes
+// published messages to subscribers using the same wildcard rules as
+// NATS subjects: "*" matches a single token, and ">" matches one or more
+// trailing tokens.
+//
+// The zero value is not usable; create one with NewConn.
+type Conn struct {
+	mu     sync.RWMutex
+	subs   map[*subscription]struct{}
+	closed bool
+
+	qcMu     sync.Mutex
+	qcounter map[string]uint64
+}
+
+// subscription is a single ChanSubscribe or ChanQueueSubscribe
+// registration.
+type subscription struct {
+	c       *Conn
+	subject []string
+	queue   string
+	ch      chan *res.IncomingMsg
+}
+
+// NewConn creates a new in-memory Conn with no subscribers.
+func NewConn() *Conn {
+	return &Conn{
+		subs:     make(map[*subscription]struct{}),
+		qcounter: make(map[string]uint64),
+	}
+}
+
+// Publish implements the res.Conn interface.
+func (c *Conn) Publish(subject string, payload []byte) error {
+	return c.PublishRequest(subject, "", payload)
+}
+
+// PublishRequest implements the res.Conn interface.
+func (c *Conn) PublishRequest(subject, reply string, data []byte) error {
+	tokens := strings.Split(subject, ".")
+
+	// Collect the matching subscriptions under the read lock, then
+	// release it before delivering. Delivering while holding the lock
+	// would let a slow or full subscriber channel block Publish
+	// indefinitely, and since Close/ChanSubscribe/ChanQueueSubscribe all
+	// need the write lock, they'd deadlock behind it.
+	c.mu.RLock()
+	if c.closed {
+		c.mu.RUnlock()
+		return nil
+	}
+	var plain []*subscription
+	queueMatches := make(map[string][]*subscription)
+	for sub := range c.subs {
+		if !subjectMatch(sub.subject, tokens) {
+			continue
+		}
+		if sub.queue == "" {
+			plain = append(plain, sub)
+			continue
+		}
+		queueMatches[sub.queue] = append(queueMatches[sub.queue], sub)
+	}
+	c.mu.RUnlock()
+
+	for _, sub := range plain {
+		sub.deliver(subject, reply, data)
+	}
+	for queue, subs := range queueMatches {
+		subs[c.nextQueueIndex(queue, len(subs))].deliver(subject, reply, data)
+	}
+
+	return nil
+}
+
+// ChanSubscribe implements the res.Conn interface.
+func (c *Conn) ChanSubscribe(subject string, ch chan *res.IncomingMsg) (res.Subscription, error) {
+	return c.subscribe(subject, "", ch)
+}
+
+// ChanQueueSubscribe implements the res.Conn interface.
+func (c *Conn) ChanQueueSubscribe(subject, queue string, ch chan *res.IncomingMsg) (res.Subscription, error) {
+	return c.subscribe(subject, queue, ch)
+}
+
+func (c *Conn) subscribe(subject, queue string, ch chan *res.IncomingMsg) (res.Subscription, error) {
+	sub := &subscription{
+		c:       c,
+		subject: strings.Split(subject, "."),
+		queue:   queue,
+		ch:      ch,
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.subs[sub] = struct{}{}
+
+	return sub, nil
+}
+
+// Close implements the res.Conn interface.
+func (c *Conn) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closed = true
+	c.subs = make(map[*subscription]struct{})
+}
+
+func (s *subscription) deliver(subject, reply string, data []byte) {
+	s.ch <- &res.IncomingMsg{Subject: subject, Reply: reply, Data: data}
+}
+
+// Unsubscribe implements the res.Subscription interface.
+func (s *subscription) Unsubscribe() error {
+	s.c.mu.Lock()
+	defer s.c.mu.Unlock()
+	delete(s.c.subs, s)
+	return nil
+}
+
+// nextQueueIndex round-robins delivery within a queue group so repeated
+// publishes spread across its members rather than always hitting the
+// first one. The counter is scoped to this Conn and keyed by queue name
+// only, so unrelated Conns never share round-robin state, while queue
+// groups of the same name on different subjects share one counter.
+func (c *Conn) nextQueueIndex(queue string, n int) int {
+	c.qcMu.Lock()
+	defer c.qcMu.Unlock()
+	i := c.qcounter[queue]
+	c.qcounter[queue] = i + 1
+	return int(i) % n
+}
+
+// subjectMatch reports whether tokens (a published subject split on ".")
+// matches pattern (a subscription subject split on "."), where "*"
+// matches a single token and ">" matches one or more trailing tokens.
+func subjectMatch(pattern, tokens []string) bool {
+	for i, p := range pattern {
+		if p == ">" {
+			return i < len(tokens)
+		}
+		if i >= len(tokens) {
+			return false
+		}
+		if p != "*" && p != tokens[i] {
+			return false
+		}
+	}
+	return len(pattern) == len(tokens)
+}
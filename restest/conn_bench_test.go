@@ -0,0 +1,47 @@
+package restest
+
+import (
+	"testing"
+
+	res "github.com/jirenius/go-res"
+)
+
+func BenchmarkConnPublishSubscribe(b *testing.B) {
+	c := NewConn()
+	ch := make(chan *res.IncomingMsg, 1)
+	c.ChanSubscribe("test.model.foo", ch)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.Publish("test.model.foo", []byte("data"))
+		<-ch
+	}
+}
+
+func BenchmarkConnPublishWildcardMatch(b *testing.B) {
+	c := NewConn()
+	ch := make(chan *res.IncomingMsg, 1)
+	c.ChanSubscribe("test.model.>", ch)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.Publish("test.model.foo.bar", []byte("data"))
+		<-ch
+	}
+}
+
+func BenchmarkConnPublishManySubscribers(b *testing.B) {
+	c := NewConn()
+	ch := make(chan *res.IncomingMsg, 1)
+	for i := 0; i < 999; i++ {
+		other := make(chan *res.IncomingMsg, 1)
+		c.ChanSubscribe("test.other.>", other)
+	}
+	c.ChanSubscribe("test.model.foo", ch)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.Publish("test.model.foo", []byte("data"))
+		<-ch
+	}
+}
@@ -0,0 +1,125 @@
+package restest
+
+import (
+	"testing"
+	"time"
+
+	res "github.com/jirenius/go-res"
+)
+
+func TestConnPublishSubscribe(t *testing.T) {
+	c := NewConn()
+	ch := make(chan *res.IncomingMsg, 1)
+
+	if _, err := c.ChanSubscribe("test.model.foo", ch); err != nil {
+		t.Fatalf("unexpected error subscribing: %s", err)
+	}
+
+	if err := c.Publish("test.model.foo", []byte("data")); err != nil {
+		t.Fatalf("unexpected error publishing: %s", err)
+	}
+
+	assertMsg(t, ch, "test.model.foo", "", "data")
+}
+
+func TestConnPublishRequestWithReply(t *testing.T) {
+	c := NewConn()
+	ch := make(chan *res.IncomingMsg, 1)
+
+	if _, err := c.ChanSubscribe("test.model.foo", ch); err != nil {
+		t.Fatalf("unexpected error subscribing: %s", err)
+	}
+
+	if err := c.PublishRequest("test.model.foo", "_INBOX.abc", []byte("data")); err != nil {
+		t.Fatalf("unexpected error publishing: %s", err)
+	}
+
+	assertMsg(t, ch, "test.model.foo", "_INBOX.abc", "data")
+}
+
+func TestConnSingleTokenWildcard(t *testing.T) {
+	c := NewConn()
+	ch := make(chan *res.IncomingMsg, 1)
+
+	if _, err := c.ChanSubscribe("test.model.*", ch); err != nil {
+		t.Fatalf("unexpected error subscribing: %s", err)
+	}
+
+	c.Publish("test.model.foo", []byte("data"))
+	assertMsg(t, ch, "test.model.foo", "", "data")
+
+	c.Publish("test.model.foo.bar", nil)
+	assertNoMsg(t, ch)
+}
+
+func TestConnTrailingWildcard(t *testing.T) {
+	c := NewConn()
+	ch := make(chan *res.IncomingMsg, 1)
+
+	if _, err := c.ChanSubscribe("test.model.>", ch); err != nil {
+		t.Fatalf("unexpected error subscribing: %s", err)
+	}
+
+	c.Publish("test.model.foo.bar", []byte("data"))
+	assertMsg(t, ch, "test.model.foo.bar", "", "data")
+}
+
+func TestConnQueueSubscribeDeliversToOneMember(t *testing.T) {
+	c := NewConn()
+	ch1 := make(chan *res.IncomingMsg, 4)
+	ch2 := make(chan *res.IncomingMsg, 4)
+
+	c.ChanQueueSubscribe("test.model.foo", "workers", ch1)
+	c.ChanQueueSubscribe("test.model.foo", "workers", ch2)
+
+	for i := 0; i < 4; i++ {
+		c.Publish("test.model.foo", []byte("data"))
+	}
+
+	if got := len(ch1) + len(ch2); got != 4 {
+		t.Errorf("expected 4 total messages delivered, got %d", got)
+	}
+}
+
+func TestConnUnsubscribeStopsDelivery(t *testing.T) {
+	c := NewConn()
+	ch := make(chan *res.IncomingMsg, 1)
+
+	sub, err := c.ChanSubscribe("test.model.foo", ch)
+	if err != nil {
+		t.Fatalf("unexpected error subscribing: %s", err)
+	}
+	if err := sub.Unsubscribe(); err != nil {
+		t.Fatalf("unexpected error unsubscribing: %s", err)
+	}
+
+	c.Publish("test.model.foo", []byte("data"))
+	assertNoMsg(t, ch)
+}
+
+func assertMsg(t *testing.T, ch chan *res.IncomingMsg, subject, reply, data string) {
+	t.Helper()
+	select {
+	case m := <-ch:
+		if m.Subject != subject {
+			t.Errorf("expected subject %q, got %q", subject, m.Subject)
+		}
+		if m.Reply != reply {
+			t.Errorf("expected reply %q, got %q", reply, m.Reply)
+		}
+		if string(m.Data) != data {
+			t.Errorf("expected data %q, got %q", data, string(m.Data))
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected a message, got none")
+	}
+}
+
+func assertNoMsg(t *testing.T, ch chan *res.IncomingMsg) {
+	t.Helper()
+	select {
+	case m := <-ch:
+		t.Fatalf("expected no message, got one on subject %q", m.Subject)
+	case <-time.After(10 * time.Millisecond):
+	}
+}
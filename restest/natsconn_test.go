@@ -0,0 +1,28 @@
+package restest
+
+import (
+	"testing"
+
+	res "github.com/jirenius/go-res"
+	nats "github.com/nats-io/nats.go"
+	natstest "github.com/nats-io/nats-server/v2/test"
+)
+
+// TestConnScenariosNATS runs the same conformance suite used for the
+// in-memory Conn against a real, embedded NATS server, so that
+// restest.Conn is verified to behave the same way as the NATS-backed
+// Conn for the scenarios Service relies on.
+func TestConnScenariosNATS(t *testing.T) {
+	opts := natstest.DefaultTestOptions
+	opts.Port = -1 // pick a free port
+	srv := natstest.RunServer(&opts)
+	defer srv.Shutdown()
+
+	RunConnScenarios(t, func() res.Conn {
+		nc, err := nats.Connect(srv.ClientURL())
+		if err != nil {
+			t.Fatalf("failed to connect to embedded nats server: %s", err)
+		}
+		return res.NewNATSConn(nc)
+	})
+}
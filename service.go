@@ -0,0 +1,101 @@
+package res
+
+import "sync"
+
+// Service is a RES service exposing one or more resources under a
+// resource name prefix, dispatched over a Conn.
+type Service struct {
+	// nc is the connection requests are received on and responses are
+	// sent over. It is transport-agnostic; see Conn.
+	nc   Conn
+	name string
+
+	mu  sync.Mutex
+	sub Subscription
+
+	// inCh receives every IncomingMsg matching the service's resource
+	// prefix. pending and pendingBuf are swapped on each processing pass
+	// so that handler dispatch never runs while holding mu.
+	inCh       chan *IncomingMsg
+	pending    []*IncomingMsg
+	pendingBuf []*IncomingMsg
+
+	// authorizer is consulted by RequireScope to derive access responses
+	// without per-resource handler logic. See SetAuthorizer.
+	authorizer *Authorizer
+}
+
+// NewService creates a new Service with the given resource name prefix,
+// communicating over nc.
+func NewService(nc Conn, name string) *Service {
+	return &Service{
+		nc:   nc,
+		name: name,
+		inCh: make(chan *IncomingMsg, 256),
+	}
+}
+
+// Start subscribes to the service's resource prefix and begins
+// dispatching incoming requests to registered handlers.
+func (s *Service) Start() error {
+	sub, err := s.nc.ChanSubscribe(s.name+".>", s.inCh)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.sub = sub
+	s.mu.Unlock()
+
+	go s.processRequests()
+	return nil
+}
+
+// Shutdown unsubscribes the service, closes inCh so processRequests can
+// return, and closes its connection. Neither natsConn nor restest.Conn
+// closes a caller-supplied channel on Unsubscribe (only natsConn closes
+// its own internal relay channel), so inCh is ours to close.
+func (s *Service) Shutdown() error {
+	s.mu.Lock()
+	sub := s.sub
+	s.mu.Unlock()
+
+	if sub != nil {
+		if err := sub.Unsubscribe(); err != nil {
+			return err
+		}
+	}
+	close(s.inCh)
+	s.nc.Close()
+	return nil
+}
+
+// processRequests drains inCh, swapping the accumulated batch into
+// pendingBuf so that dispatch runs without holding mu.
+func (s *Service) processRequests() {
+	for m := range s.inCh {
+		s.mu.Lock()
+		s.pending = append(s.pending, m)
+		s.pendingBuf, s.pending = s.pending, s.pendingBuf[:0]
+		batch := s.pendingBuf
+		s.mu.Unlock()
+
+		for _, msg := range batch {
+			s.dispatch(msg)
+		}
+	}
+}
+
+// notFoundResponse is the RES protocol error response for a request with
+// no matching resource handler.
+const notFoundResponse = `{"error":{"code":"system.notFound","message":"Not found"}}`
+
+// dispatch replies with a system.notFound error. Resource and method
+// routing through registered handlers is done by Handle, Auth and Access
+// (not part of this file).
+func (s *Service) dispatch(m *IncomingMsg) {
+	if m.Reply == "" {
+		return
+	}
+	s.nc.Publish(m.Reply, []byte(notFoundResponse))
+}
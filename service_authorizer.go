@@ -0,0 +1,15 @@
+package res
+
+// SetAuthorizer sets the Authorizer used by RequireScope when deriving
+// access responses for handlers registered without a rule of their own.
+// It must be called before the service is started.
+func (s *Service) SetAuthorizer(a *Authorizer) *Service {
+	s.authorizer = a
+	return s
+}
+
+// Authorizer returns the Authorizer set with SetAuthorizer, or nil if
+// none has been set.
+func (s *Service) Authorizer() *Authorizer {
+	return s.authorizer
+}